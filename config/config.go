@@ -0,0 +1,77 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+// Package config holds banshee's on-disk configuration shapes. It is a leaf
+// package: nothing in here imports detector or its subpackages, so that
+// config can stay the thing everything else depends on rather than the
+// other way around. Packages that need a detector/input.Config or
+// detector/cluster.Config build one from the plain structs below instead of
+// config importing those packages' types directly.
+package config
+
+import "time"
+
+// Config is banshee's top level configuration.
+type Config struct {
+	Detector DetectorConfig `json:"detector"`
+}
+
+// DetectorConfig configures the detector.
+type DetectorConfig struct {
+	// Port is the tcp port used for the historical single banshee-format
+	// listener when Inputs is left empty.
+	Port int `json:"port"`
+	// Factor is the 3-sigma cursor's trending factor.
+	Factor float64 `json:"factor"`
+	// LeastCount is the minimum number of samples a cursor needs before it
+	// starts scoring.
+	LeastCount int `json:"least_count"`
+	// BlackList holds metric name patterns to always ignore.
+	BlackList []string `json:"black_list"`
+	// Inputs lists every protocol listener the detector should start. A
+	// nil/empty list falls back to a single banshee-format tcp listener on
+	// Port.
+	Inputs []InputConfig `json:"inputs"`
+	// StateShards is the number of state write-back shards. The detector
+	// package applies its own default when this is <= 0.
+	StateShards int `json:"state_shards"`
+	// FlushInterval is how often a state shard flushes its write-back cache
+	// even if it hasn't hit MaxBatchSize. The detector package applies its
+	// own default when this is <= 0.
+	FlushInterval time.Duration `json:"flush_interval"`
+	// MaxBatchSize is the number of states a shard accumulates before
+	// flushing early. The detector package applies its own default when
+	// this is <= 0.
+	MaxBatchSize int `json:"max_batch_size"`
+	// Cluster enables federation across several detector nodes when set.
+	Cluster *ClusterConfig `json:"cluster"`
+}
+
+// InputConfig describes how to configure and run a single protocol
+// listener. Its fields mirror detector/input.Config; it's kept as a plain
+// struct here, rather than config importing detector/input, so that a leaf
+// package like config never depends on the detector tree that depends on
+// it. The detector package converts an InputConfig to an input.Config when
+// it builds each listener.
+type InputConfig struct {
+	Protocol  string `json:"protocol"`
+	Port      int    `json:"port"`
+	Transport string `json:"transport"`
+	Alias     string `json:"alias"`
+}
+
+// ClusterConfig configures federation across several detector nodes. Its
+// fields mirror detector/cluster.Config, kept as a plain struct here for
+// the same layering reason as InputConfig.
+type ClusterConfig struct {
+	Self              string        `json:"self"`
+	Peers             []string      `json:"peers"`
+	Etcd              []string      `json:"etcd"`
+	EtcdPrefix        string        `json:"etcd_prefix"`
+	HeartbeatInterval time.Duration `json:"heartbeat_interval"`
+}
+
+// LeastC returns the minimum number of samples a cursor needs before it
+// starts scoring.
+func (c *Config) LeastC() int {
+	return c.Detector.LeastCount
+}
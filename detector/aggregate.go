@@ -0,0 +1,95 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package detector
+
+import (
+	"time"
+
+	"github.com/eleme/banshee/detector/aggregator"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util"
+	"github.com/eleme/banshee/util/log"
+)
+
+// defaultAggregatorGrace is how long a sample may arrive before its rule's
+// aggregation period start before RunningAggregator drops it instead of
+// rolling it into that period.
+const defaultAggregatorGrace = 5 * time.Second
+
+// aggregatorFlushInterval is how often Detector checks for aggregation
+// periods whose grace window has elapsed and flushes them.
+const aggregatorFlushInterval = time.Second
+
+// ruleFor returns the first rule whose pattern matches m, the same rules
+// match already confirmed hit at least one of.
+func (d *Detector) ruleFor(m *models.Metric) *models.Rule {
+	for _, rule := range d.db.Admin.GetRules() {
+		if util.Match(m.Name, rule.Pattern) {
+			return &rule
+		}
+	}
+	return nil
+}
+
+// aggregate routes m into its rule's RunningAggregator window when the rule
+// opts into aggregation via rule.Aggregator, and reports whether the raw
+// metric should still be forwarded to the detect pipeline: false once
+// rule.DropOriginal suppresses it in favor of the rolled-up series.
+func (d *Detector) aggregate(rule *models.Rule, m *models.Metric) bool {
+	if rule == nil || rule.Aggregator == "" {
+		return true
+	}
+	period, err := time.ParseDuration(rule.Period)
+	if err != nil {
+		log.Error("aggregate %s: parse period %q: %v, forwarding raw metric..", m.Name, rule.Period, err)
+		return true
+	}
+	aggRule := aggregator.Rule{
+		Pattern:    rule.Pattern,
+		Aggregator: rule.Aggregator,
+		Period:     period,
+		Grace:      defaultAggregatorGrace,
+	}
+	if err := d.aggregator.Add(aggRule, m); err != nil {
+		log.Error("aggregate %s: %v, forwarding raw metric..", m.Name, err)
+		return true
+	}
+	return !rule.DropOriginal
+}
+
+// runAggregatorFlusher periodically flushes RunningAggregator windows whose
+// grace window has elapsed, routing the synthetic metrics they emit back
+// through onAggregated so the 3-sigma cursor sees them.
+func (d *Detector) runAggregatorFlusher() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(aggregatorFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.aggregator.Flush(time.Now(), d.onAggregated)
+		case <-d.ctx.Done():
+			d.aggregator.Flush(time.Now(), d.onAggregated)
+			return
+		}
+	}
+}
+
+// onAggregated handles a synthetic metric emitted by a flushed aggregation
+// window, eg. "api.latency.mean". It must bypass process's aggregate/
+// ruleFor step rather than reuse it: re-matching m.Name against the rules
+// risks the synthetic name hitting its own source rule's pattern (eg.
+// "api.latency*"), rolling it into a new aggregation window under that
+// same rule, and with rule.DropOriginal set, silently swallowing it
+// forever. So, aside from the federation ownership check every metric
+// needs, a synthetic metric always goes straight to the detect pipeline.
+func (d *Detector) onAggregated(m *models.Metric) {
+	if d.cluster != nil && !d.cluster.Owns(m.Name) {
+		d.cluster.Forward(m)
+		return
+	}
+	if d.cluster != nil {
+		d.originOf.Store(m, d.self())
+	}
+	d.batcher.submit(m)
+}
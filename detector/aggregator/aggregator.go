@@ -0,0 +1,40 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+// Package aggregator rolls raw metric samples up into periodic windows
+// before they reach the detector's 3-sigma cursor, modeled on Telegraf's
+// aggregator plugins: a rule can opt a pattern into "basicstats",
+// "histogram" or "percentile" aggregation over a configured period, so
+// noisy metrics detect on stable rolled-up values instead of raw samples.
+package aggregator
+
+import (
+	"fmt"
+
+	"github.com/eleme/banshee/models"
+)
+
+// Aggregator accumulates samples for one aggregation period and, when
+// pushed, emits the synthetic metrics for that period.
+type Aggregator interface {
+	// Add adds a sample to the current period's accumulator.
+	Add(m *models.Metric)
+	// Push computes the synthetic metrics for the samples added since the
+	// last Push, named "name.<suffix>" and stamped at stamp, then resets
+	// the accumulator for the next period.
+	Push(name string, stamp int64) []*models.Metric
+}
+
+// New creates the Aggregator named by kind: "basicstats", "histogram" or
+// "percentile".
+func New(kind string) (Aggregator, error) {
+	switch kind {
+	case "basicstats":
+		return newBasicStats(), nil
+	case "histogram":
+		return newHistogram(), nil
+	case "percentile":
+		return newPercentile(), nil
+	default:
+		return nil, fmt.Errorf("aggregator: unknown kind %q", kind)
+	}
+}
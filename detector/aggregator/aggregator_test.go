@@ -0,0 +1,86 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package aggregator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/eleme/banshee/models"
+)
+
+func TestNewUnknownKind(t *testing.T) {
+	if _, err := New("bogus"); err == nil {
+		t.Fatal("expected an error for an unknown aggregator kind")
+	}
+}
+
+func TestBasicStatsPush(t *testing.T) {
+	a, err := New("basicstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []float64{1, 2, 3, 4} {
+		a.Add(&models.Metric{Value: v})
+	}
+	ms := a.Push("x", 100)
+	got := make(map[string]float64, len(ms))
+	for _, m := range ms {
+		got[m.Name] = m.Value
+	}
+	want := map[string]float64{
+		"x.count":  4,
+		"x.mean":   2.5,
+		"x.stddev": math.Sqrt(1.25),
+		"x.min":    1,
+		"x.max":    4,
+	}
+	for name, v := range want {
+		if math.Abs(got[name]-v) > 1e-9 {
+			t.Errorf("%s = %v, want %v", name, got[name], v)
+		}
+	}
+	// Push resets the accumulator for the next period.
+	if ms := a.Push("x", 200); ms != nil {
+		t.Errorf("Push on an empty accumulator = %v, want nil", ms)
+	}
+}
+
+func TestHistogramPush(t *testing.T) {
+	a, err := New("histogram")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9} {
+		a.Add(&models.Metric{Value: v})
+	}
+	ms := a.Push("x", 100)
+	if len(ms) != histogramBuckets {
+		t.Fatalf("got %d buckets, want %d", len(ms), histogramBuckets)
+	}
+	total := 0.0
+	for _, m := range ms {
+		total += m.Value
+	}
+	if total != 10 {
+		t.Errorf("bucket counts sum to %v, want 10", total)
+	}
+}
+
+func TestPercentilePush(t *testing.T) {
+	a, err := New("percentile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 100; i++ {
+		a.Add(&models.Metric{Value: float64(i)})
+	}
+	ms := a.Push("x", 100)
+	got := make(map[string]float64, len(ms))
+	for _, m := range ms {
+		got[m.Name] = m.Value
+	}
+	if got["x.p50"] <= 0 || got["x.p50"] >= got["x.p99"] {
+		t.Errorf("expected p50 < p99, got p50=%v p99=%v", got["x.p50"], got["x.p99"])
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package aggregator
+
+import (
+	"math"
+
+	"github.com/eleme/banshee/models"
+)
+
+// basicStats accumulates count, sum, sum of squares, min and max, and on
+// Push emits name.count, name.mean, name.stddev, name.min and name.max.
+type basicStats struct {
+	count int
+	sum   float64
+	sumSq float64
+	min   float64
+	max   float64
+}
+
+func newBasicStats() *basicStats {
+	return &basicStats{}
+}
+
+// Add implements Aggregator.
+func (a *basicStats) Add(m *models.Metric) {
+	if a.count == 0 {
+		a.min, a.max = m.Value, m.Value
+	} else {
+		if m.Value < a.min {
+			a.min = m.Value
+		}
+		if m.Value > a.max {
+			a.max = m.Value
+		}
+	}
+	a.count++
+	a.sum += m.Value
+	a.sumSq += m.Value * m.Value
+}
+
+// Push implements Aggregator.
+func (a *basicStats) Push(name string, stamp int64) []*models.Metric {
+	if a.count == 0 {
+		return nil
+	}
+	mean := a.sum / float64(a.count)
+	variance := a.sumSq/float64(a.count) - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	ms := []*models.Metric{
+		{Name: name + ".count", Stamp: stamp, Value: float64(a.count)},
+		{Name: name + ".mean", Stamp: stamp, Value: mean},
+		{Name: name + ".stddev", Stamp: stamp, Value: math.Sqrt(variance)},
+		{Name: name + ".min", Stamp: stamp, Value: a.min},
+		{Name: name + ".max", Stamp: stamp, Value: a.max},
+	}
+	*a = basicStats{}
+	return ms
+}
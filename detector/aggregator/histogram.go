@@ -0,0 +1,66 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package aggregator
+
+import (
+	"fmt"
+
+	"github.com/eleme/banshee/models"
+)
+
+// histogramBuckets is the number of equal-width buckets a histogram splits
+// each period's observed value range into.
+const histogramBuckets = 10
+
+// histogram buckets a period's samples into histogramBuckets equal-width
+// buckets spanning the period's observed [min, max], and on Push emits one
+// synthetic metric per bucket: name.bucket.<n> = count of samples that fell
+// in bucket n.
+type histogram struct {
+	samples []float64
+}
+
+func newHistogram() *histogram {
+	return &histogram{}
+}
+
+// Add implements Aggregator.
+func (a *histogram) Add(m *models.Metric) {
+	a.samples = append(a.samples, m.Value)
+}
+
+// Push implements Aggregator.
+func (a *histogram) Push(name string, stamp int64) []*models.Metric {
+	if len(a.samples) == 0 {
+		return nil
+	}
+	min, max := a.samples[0], a.samples[0]
+	for _, v := range a.samples {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	width := (max - min) / float64(histogramBuckets)
+	counts := make([]int, histogramBuckets)
+	for _, v := range a.samples {
+		idx := histogramBuckets - 1
+		if width > 0 {
+			idx = int((v - min) / width)
+			if idx < 0 {
+				idx = 0
+			} else if idx >= histogramBuckets {
+				idx = histogramBuckets - 1
+			}
+		}
+		counts[idx]++
+	}
+	ms := make([]*models.Metric, histogramBuckets)
+	for i, c := range counts {
+		ms[i] = &models.Metric{Name: fmt.Sprintf("%s.bucket.%d", name, i), Stamp: stamp, Value: float64(c)}
+	}
+	a.samples = a.samples[:0]
+	return ms
+}
@@ -0,0 +1,49 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package aggregator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/eleme/banshee/models"
+)
+
+// percentileTargets are the percentiles emitted on Push.
+var percentileTargets = []float64{50, 90, 99}
+
+// percentile buffers every sample of the period and, on Push, sorts them to
+// compute exact percentiles. It's a simple stand-in for a t-digest: exact
+// at the sample volumes a single rule sees per period, at the cost of
+// keeping every sample in memory for the whole period.
+type percentile struct {
+	samples []float64
+}
+
+func newPercentile() *percentile {
+	return &percentile{}
+}
+
+// Add implements Aggregator.
+func (a *percentile) Add(m *models.Metric) {
+	a.samples = append(a.samples, m.Value)
+}
+
+// Push implements Aggregator.
+func (a *percentile) Push(name string, stamp int64) []*models.Metric {
+	if len(a.samples) == 0 {
+		return nil
+	}
+	sort.Float64s(a.samples)
+	ms := make([]*models.Metric, 0, len(percentileTargets))
+	for _, p := range percentileTargets {
+		idx := int(p / 100 * float64(len(a.samples)-1))
+		ms = append(ms, &models.Metric{
+			Name:  fmt.Sprintf("%s.p%d", name, int(p)),
+			Stamp: stamp,
+			Value: a.samples[idx],
+		})
+	}
+	a.samples = a.samples[:0]
+	return ms
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package aggregator
+
+import (
+	"sync"
+	"time"
+
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util/log"
+)
+
+// Rule is the subset of a detection rule a RunningAggregator needs: which
+// kind of Aggregator to run, over what period, and how long a sample may
+// arrive after a period's end before it's dropped instead of rolled into
+// it.
+type Rule struct {
+	Pattern    string
+	Aggregator string
+	Period     time.Duration
+	Grace      time.Duration
+}
+
+// window is one (rule, metric name)'s in-progress aggregation period.
+type window struct {
+	agg         Aggregator
+	periodStart time.Time
+	periodEnd   time.Time
+	period      time.Duration
+	grace       time.Duration
+}
+
+// RunningAggregator keys a window per (rule pattern, metric name) and, once
+// Flush observes a window whose grace period has elapsed, emits the
+// synthetic "name.<suffix>" metrics it accumulated and opens the next
+// period. Samples older than their window's periodStart minus its grace
+// are dropped rather than rolled into a period that's already closed.
+type RunningAggregator struct {
+	mu      sync.Mutex
+	windows map[string]*window
+}
+
+// NewRunningAggregator creates an empty RunningAggregator.
+func NewRunningAggregator() *RunningAggregator {
+	return &RunningAggregator{windows: make(map[string]*window)}
+}
+
+// Add routes m into rule's window for m.Name, opening the window on first
+// sight with periodStart aligned to m's own timestamp. Samples that arrive
+// more than rule.Grace before their window's periodStart are dropped with a
+// debug log instead of skewing a period that's already been pushed.
+func (r *RunningAggregator) Add(rule Rule, m *models.Metric) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := rule.Pattern + "\x00" + m.Name
+	w, ok := r.windows[key]
+	if !ok {
+		agg, err := New(rule.Aggregator)
+		if err != nil {
+			return err
+		}
+		stamp := time.Unix(m.Stamp, 0)
+		w = &window{agg: agg, periodStart: stamp, periodEnd: stamp.Add(rule.Period), period: rule.Period, grace: rule.Grace}
+		r.windows[key] = w
+	}
+	stamp := time.Unix(m.Stamp, 0)
+	if stamp.Before(w.periodStart.Add(-rule.Grace)) {
+		log.Debug("%s: sample stamped %s is before period %s (grace %s), dropping..", m.Name, stamp, w.periodStart, rule.Grace)
+		return nil
+	}
+	w.agg.Add(m)
+	return nil
+}
+
+// Flush emits and resets every window whose periodEnd plus its grace has
+// passed as of now, calling onMetric for each synthetic metric produced.
+func (r *RunningAggregator) Flush(now time.Time, onMetric func(*models.Metric)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, w := range r.windows {
+		if now.Before(w.periodEnd.Add(w.grace)) {
+			continue
+		}
+		for _, m := range w.agg.Push(metricNameOf(key), w.periodEnd.Unix()) {
+			onMetric(m)
+		}
+		w.periodStart = w.periodEnd
+		w.periodEnd = w.periodStart.Add(w.period)
+	}
+}
+
+// metricNameOf recovers the metric name half of a "pattern\x00name" key.
+func metricNameOf(key string) string {
+	for i := 0; i < len(key); i++ {
+		if key[i] == 0 {
+			return key[i+1:]
+		}
+	}
+	return key
+}
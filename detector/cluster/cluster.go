@@ -0,0 +1,303 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+// Package cluster lets several banshee detector nodes share detection load.
+// Each metric is deterministically routed to exactly one owning node by
+// rendezvous hashing over the metric name, so the state for any given
+// metric always lives on one node no matter how many nodes are in the
+// cluster. Peers are configured statically or discovered via etcd, the
+// same discovery model BanyanDB uses for its distributed queue, connect to
+// each other over a small framed binary protocol, and heartbeat one
+// another so the ring reshards around membership changes and quarantines
+// unreachable peers. Results produced anywhere flow back to the node that
+// originally received the metric, so that node's alerter/webapp see a
+// single unified stream regardless of which node actually ran detect.
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util/log"
+)
+
+// defaultHeartbeatInterval is how often peers are pinged to detect
+// membership changes and quarantine unreachable peers.
+const defaultHeartbeatInterval = 5 * time.Second
+
+// Config configures a Cluster.
+type Config struct {
+	// Self is this node's own address, as peers see it, eg. "10.0.0.1:9500".
+	Self string `json:"self"`
+	// Peers statically lists every other node's address. Leave it empty
+	// (and set Etcd) to discover peers instead.
+	Peers []string `json:"peers"`
+	// Etcd, when set, is the etcd endpoint list used to discover peers
+	// registered under EtcdPrefix instead of using a static Peers list.
+	Etcd []string `json:"etcd"`
+	// EtcdPrefix is the etcd key prefix peers register themselves under.
+	EtcdPrefix string `json:"etcd_prefix"`
+	// HeartbeatInterval is how often peers are pinged to detect membership
+	// changes and quarantine unreachable peers. Defaults to 5s.
+	HeartbeatInterval time.Duration `json:"heartbeat_interval"`
+}
+
+// Cluster routes metrics to whichever node owns them and exposes the ones
+// this node owns to local detection.
+type Cluster struct {
+	cfg  Config
+	self string
+
+	mu    sync.RWMutex
+	peers map[string]*peer
+
+	// onOwned is called with (origin, m) whenever this node should detect
+	// m, whether m arrived locally or was forwarded here because this node
+	// owns it. onResult is called whenever a detected metric should be
+	// published to this node's own results channel.
+	onOwned  func(origin string, m *models.Metric)
+	onResult func(m *models.Metric)
+
+	ln   net.Listener
+	wg   sync.WaitGroup
+	done chan struct{}
+}
+
+// New creates a Cluster. onOwned is called for every metric this node
+// owns; onResult is called for every detected metric that should flow into
+// this node's own results channel.
+func New(cfg Config, onOwned func(origin string, m *models.Metric), onResult func(m *models.Metric)) (*Cluster, error) {
+	if cfg.Self == "" {
+		return nil, fmt.Errorf("cluster: Config.Self is required")
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = defaultHeartbeatInterval
+	}
+	c := &Cluster{
+		cfg:      cfg,
+		self:     cfg.Self,
+		peers:    make(map[string]*peer),
+		onOwned:  onOwned,
+		onResult: onResult,
+		done:     make(chan struct{}),
+	}
+	for _, addr := range cfg.Peers {
+		c.addPeer(addr)
+	}
+	return c, nil
+}
+
+// Self returns this node's own address.
+func (c *Cluster) Self() string {
+	return c.self
+}
+
+// Start opens this node's listener for inbound forwards and results,
+// begins heartbeating peers, and if cfg.Etcd is set, registers this node
+// and watches for membership changes. It returns once the listener is
+// bound; the accept, heartbeat and discovery loops keep running in the
+// background until ctx is done.
+func (c *Cluster) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", c.self)
+	if err != nil {
+		return fmt.Errorf("cluster: failed to bind tcp://%s: %v", c.self, err)
+	}
+	c.ln = ln
+	go func() {
+		<-ctx.Done()
+		close(c.done)
+		ln.Close()
+	}()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.acceptLoop(ln)
+	}()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.heartbeatLoop(ctx)
+	}()
+	if len(c.cfg.Etcd) > 0 {
+		if err := c.registerAndWatchEtcd(ctx); err != nil {
+			return fmt.Errorf("cluster: etcd discovery: %v", err)
+		}
+	}
+	return nil
+}
+
+// Stop waits for every Cluster goroutine started by Start to return.
+// Callers cancel the ctx passed to Start to trigger shutdown.
+func (c *Cluster) Stop() {
+	c.wg.Wait()
+}
+
+// Owns reports whether this node is the rendezvous-hashing owner of name
+// among the peers currently considered healthy.
+func (c *Cluster) Owns(name string) bool {
+	return c.ownerOf(name) == c.self
+}
+
+// ownerOf returns the address of whichever live node owns name.
+func (c *Cluster) ownerOf(name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	candidates := make([]string, 0, len(c.peers)+1)
+	candidates = append(candidates, c.self)
+	for addr, p := range c.peers {
+		if !p.isQuarantined() {
+			candidates = append(candidates, addr)
+		}
+	}
+	return ring{}.owner(name, candidates)
+}
+
+// Forward sends m to whichever node owns it to be detected there, tagging
+// it with this node so the eventual result flows back here. If m is
+// already owned locally it's delivered to onOwned directly.
+func (c *Cluster) Forward(m *models.Metric) {
+	addr := c.ownerOf(m.Name)
+	if addr == c.self {
+		c.onOwned(c.self, m)
+		return
+	}
+	p := c.peerByAddr(addr)
+	if p == nil {
+		return
+	}
+	if err := p.send(func(conn net.Conn) error { return writeForwardFrame(conn, c.self, m) }); err != nil {
+		log.Error("cluster: write to %s: %v, dropping connection..", p.addr, err)
+	}
+}
+
+// PublishResult sends a detected metric to whichever node should see it in
+// its own results channel: the node that originally received it before it
+// was (maybe) forwarded here for detection.
+func (c *Cluster) PublishResult(origin string, m *models.Metric) {
+	if origin == c.self {
+		c.onResult(m)
+		return
+	}
+	p := c.peerByAddr(origin)
+	if p == nil {
+		// The origin node is gone from the ring (eg. it left the cluster
+		// while this metric was in flight); there's nowhere to send the
+		// result, so publish it locally rather than drop it silently.
+		c.onResult(m)
+		return
+	}
+	if err := p.send(func(conn net.Conn) error { return writeResultFrame(conn, m) }); err != nil {
+		log.Error("cluster: write to %s: %v, dropping connection..", p.addr, err)
+	}
+}
+
+func (c *Cluster) peerByAddr(addr string) *peer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.peers[addr]
+}
+
+func (c *Cluster) addPeer(addr string) {
+	if addr == "" || addr == c.self {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.peers[addr]; !ok {
+		c.peers[addr] = newPeer(addr)
+		log.Info("cluster: peer %s joined", addr)
+	}
+}
+
+func (c *Cluster) removePeer(addr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.peers[addr]; ok {
+		p.drop()
+		delete(c.peers, addr)
+		log.Info("cluster: peer %s left", addr)
+	}
+}
+
+func (c *Cluster) acceptLoop(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-c.done:
+				return
+			default:
+				log.Error("cluster: accept: %v", err)
+				return
+			}
+		}
+		c.wg.Add(1)
+		go func(conn net.Conn) {
+			defer c.wg.Done()
+			c.handleConn(conn)
+		}(conn)
+	}
+}
+
+func (c *Cluster) handleConn(conn net.Conn) {
+	defer conn.Close()
+	closed := make(chan struct{})
+	defer close(closed)
+	go func() {
+		select {
+		case <-c.done:
+			conn.Close()
+		case <-closed:
+		}
+	}()
+	for {
+		typ, origin, m, err := readFrame(conn)
+		if err != nil {
+			if err != io.EOF {
+				log.Error("cluster: read frame: %v, closing conn..", err)
+			}
+			return
+		}
+		switch typ {
+		case frameForward:
+			c.onOwned(origin, m)
+		case frameResult:
+			c.onResult(m)
+		}
+	}
+}
+
+func (c *Cluster) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.heartbeatOnce()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Cluster) heartbeatOnce() {
+	c.mu.RLock()
+	peers := make([]*peer, 0, len(c.peers))
+	for _, p := range c.peers {
+		peers = append(peers, p)
+	}
+	c.mu.RUnlock()
+	now := time.Now()
+	for _, p := range peers {
+		if _, err := p.dial(); err != nil {
+			log.Warn("cluster: peer %s unreachable: %v, quarantining..", p.addr, err)
+			p.markUnreachable()
+			continue
+		}
+		p.markSeen(now)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package cluster
+
+import (
+	"context"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/eleme/banshee/util/log"
+)
+
+// registerAndWatchEtcd registers this node under cfg.EtcdPrefix/self with a
+// lease it keeps alive for as long as ctx lives, then watches the prefix
+// so peers joining or leaving update c.peers live, mirroring how BanyanDB
+// discovers nodes for its distributed queue.
+func (c *Cluster) registerAndWatchEtcd(ctx context.Context) error {
+	cli, err := clientv3.New(clientv3.Config{Endpoints: c.cfg.Etcd, DialTimeout: 5 * time.Second})
+	if err != nil {
+		return err
+	}
+	lease, err := cli.Grant(ctx, 10)
+	if err != nil {
+		return err
+	}
+	key := c.cfg.EtcdPrefix + "/" + c.self
+	if _, err := cli.Put(ctx, key, c.self, clientv3.WithLease(lease.ID)); err != nil {
+		return err
+	}
+	keepAlive, err := cli.KeepAlive(ctx, lease.ID)
+	if err != nil {
+		return err
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for range keepAlive {
+			// Draining is enough: the lease client renews on our behalf.
+		}
+	}()
+	resp, err := cli.Get(ctx, c.cfg.EtcdPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	for _, kv := range resp.Kvs {
+		c.addPeer(string(kv.Value))
+	}
+	// WithPrevKV is required to read a deleted key's value back out of
+	// ev.PrevKv: without it ev.Kv.Value is empty on EventTypeDelete, so
+	// removePeer would always be called with "".
+	watch := cli.Watch(ctx, c.cfg.EtcdPrefix, clientv3.WithPrefix(), clientv3.WithPrevKV())
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for wresp := range watch {
+			for _, ev := range wresp.Events {
+				switch ev.Type {
+				case clientv3.EventTypePut:
+					c.addPeer(string(ev.Kv.Value))
+				case clientv3.EventTypeDelete:
+					if ev.PrevKv != nil {
+						c.removePeer(string(ev.PrevKv.Value))
+					}
+				default:
+					log.Warn("cluster: etcd: unexpected event type %v", ev.Type)
+				}
+			}
+		}
+	}()
+	return nil
+}
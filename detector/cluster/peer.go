@@ -0,0 +1,104 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package cluster
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// peer tracks one remote node's address, its live connection (reused
+// across forwards, results and heartbeats) and whether it's currently
+// considered healthy.
+type peer struct {
+	addr string
+
+	mu          sync.Mutex
+	conn        net.Conn
+	quarantined bool
+	lastSeen    time.Time
+
+	// writeMu serializes full frame writes against this peer's connection.
+	// A frame is a header Write followed by a payload Write; without this,
+	// two goroutines forwarding/publishing to the same peer at once could
+	// interleave their writes and corrupt the wire protocol.
+	writeMu sync.Mutex
+}
+
+func newPeer(addr string) *peer {
+	return &peer{addr: addr}
+}
+
+// dial returns the peer's live connection, (re)connecting if it doesn't
+// have one.
+func (p *peer) dial() (net.Conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		return p.conn, nil
+	}
+	conn, err := net.DialTimeout("tcp", p.addr, 3*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	p.conn = conn
+	return conn, nil
+}
+
+// drop closes and forgets the peer's connection, eg. after a write to it
+// fails, so the next dial reconnects.
+func (p *peer) drop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeLocked()
+}
+
+// markSeen records a successful heartbeat, lifting quarantine if it was
+// set.
+func (p *peer) markSeen(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastSeen = now
+	p.quarantined = false
+}
+
+// markUnreachable quarantines the peer, excluding it from the ring until
+// it's next seen healthy, and drops its connection.
+func (p *peer) markUnreachable() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.quarantined = true
+	p.closeLocked()
+}
+
+func (p *peer) closeLocked() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+func (p *peer) isQuarantined() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.quarantined
+}
+
+// send dials the peer if needed and runs write against its connection
+// under writeMu, so the whole frame goes out as one atomic write as far as
+// concurrent callers are concerned. On error the connection is dropped so
+// the next send reconnects.
+func (p *peer) send(write func(conn net.Conn) error) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	conn, err := p.dial()
+	if err != nil {
+		return err
+	}
+	if err := write(conn); err != nil {
+		p.drop()
+		return err
+	}
+	return nil
+}
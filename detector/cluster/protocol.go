@@ -0,0 +1,114 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package cluster
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/eleme/banshee/models"
+)
+
+// Frame types for the peer-to-peer wire protocol: each frame is a 1-byte
+// type, a 4-byte big-endian payload length, then the payload.
+const (
+	frameForward byte = iota + 1 // a metric forwarded to its owner for detection
+	frameResult                  // a detected metric flowing back to its origin
+)
+
+// writeForwardFrame sends m to be detected by whichever node owns it,
+// tagged with origin so the result eventually flows back there.
+func writeForwardFrame(w io.Writer, origin string, m *models.Metric) error {
+	op := []byte(origin)
+	mp := encodeMetric(m)
+	payload := make([]byte, 4+len(op)+len(mp))
+	binary.BigEndian.PutUint32(payload[0:4], uint32(len(op)))
+	copy(payload[4:], op)
+	copy(payload[4+len(op):], mp)
+	return writeFrame(w, frameForward, payload)
+}
+
+// writeResultFrame sends a detected metric to the node that should publish
+// it to its local results channel.
+func writeResultFrame(w io.Writer, m *models.Metric) error {
+	return writeFrame(w, frameResult, encodeMetric(m))
+}
+
+func writeFrame(w io.Writer, typ byte, payload []byte) error {
+	header := make([]byte, 5)
+	header[0] = typ
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readFrame reads one frame from r. origin is only set for frameForward.
+func readFrame(r io.Reader) (typ byte, origin string, m *models.Metric, err error) {
+	header := make([]byte, 5)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	typ = header[0]
+	n := binary.BigEndian.Uint32(header[1:])
+	payload := make([]byte, n)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	switch typ {
+	case frameForward:
+		if len(payload) < 4 {
+			err = fmt.Errorf("cluster: short forward frame")
+			return
+		}
+		ol := binary.BigEndian.Uint32(payload[0:4])
+		if uint32(len(payload)) < 4+ol {
+			err = fmt.Errorf("cluster: short forward frame")
+			return
+		}
+		origin = string(payload[4 : 4+ol])
+		m, err = decodeMetric(payload[4+ol:])
+	case frameResult:
+		m, err = decodeMetric(payload)
+	default:
+		err = fmt.Errorf("cluster: unknown frame type %d", typ)
+	}
+	return
+}
+
+// encodeMetric serializes a metric as [4-byte name len][name][8-byte
+// stamp][8-byte value][8-byte average][8-byte score], all big-endian.
+func encodeMetric(m *models.Metric) []byte {
+	name := []byte(m.Name)
+	buf := make([]byte, 4+len(name)+8*4)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(name)))
+	copy(buf[4:], name)
+	off := 4 + len(name)
+	binary.BigEndian.PutUint64(buf[off:], uint64(m.Stamp))
+	binary.BigEndian.PutUint64(buf[off+8:], math.Float64bits(m.Value))
+	binary.BigEndian.PutUint64(buf[off+16:], math.Float64bits(m.Average))
+	binary.BigEndian.PutUint64(buf[off+24:], math.Float64bits(m.Score))
+	return buf
+}
+
+func decodeMetric(buf []byte) (*models.Metric, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("cluster: short metric frame")
+	}
+	n := int(binary.BigEndian.Uint32(buf[0:4]))
+	off := 4 + n
+	if len(buf) < off+32 {
+		return nil, fmt.Errorf("cluster: short metric frame")
+	}
+	return &models.Metric{
+		Name:    string(buf[4:off]),
+		Stamp:   int64(binary.BigEndian.Uint64(buf[off:])),
+		Value:   math.Float64frombits(binary.BigEndian.Uint64(buf[off+8:])),
+		Average: math.Float64frombits(binary.BigEndian.Uint64(buf[off+16:])),
+		Score:   math.Float64frombits(binary.BigEndian.Uint64(buf[off+24:])),
+	}, nil
+}
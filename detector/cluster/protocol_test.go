@@ -0,0 +1,62 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package cluster
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/eleme/banshee/models"
+)
+
+func TestWriteReadForwardFrame(t *testing.T) {
+	m := &models.Metric{Name: "api.latency", Stamp: 1000, Value: 1.5, Average: 1.2, Score: 0.3}
+	var buf bytes.Buffer
+	if err := writeForwardFrame(&buf, "10.0.0.1:9500", m); err != nil {
+		t.Fatal(err)
+	}
+	typ, origin, got, err := readFrame(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != frameForward {
+		t.Errorf("typ = %d, want frameForward", typ)
+	}
+	if origin != "10.0.0.1:9500" {
+		t.Errorf("origin = %q, want %q", origin, "10.0.0.1:9500")
+	}
+	if *got != *m {
+		t.Errorf("decoded metric = %+v, want %+v", *got, *m)
+	}
+}
+
+func TestWriteReadResultFrame(t *testing.T) {
+	m := &models.Metric{Name: "api.latency", Stamp: 1000, Value: 1.5, Average: 1.2, Score: 0.3}
+	var buf bytes.Buffer
+	if err := writeResultFrame(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	typ, origin, got, err := readFrame(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != frameResult {
+		t.Errorf("typ = %d, want frameResult", typ)
+	}
+	if origin != "" {
+		t.Errorf("origin = %q, want empty for a result frame", origin)
+	}
+	if *got != *m {
+		t.Errorf("decoded metric = %+v, want %+v", *got, *m)
+	}
+}
+
+func TestReadFrameUnknownType(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeFrame(&buf, 0xFF, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := readFrame(&buf); err == nil {
+		t.Fatal("expected an error for an unknown frame type")
+	}
+}
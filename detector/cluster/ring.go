@@ -0,0 +1,37 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package cluster
+
+import "hash/fnv"
+
+// ring selects an owning peer for a metric name via rendezvous (highest
+// random weight) hashing: every candidate is scored against the key and
+// the highest scorer wins. Unlike hash(name)%N, losing or gaining one peer
+// only reshuffles ownership for the keys that scored highest against that
+// peer, not the whole keyspace.
+type ring struct{}
+
+// owner returns whichever of candidates scores highest for key, so the
+// same key always maps to the same candidate as long as it's still
+// present.
+func (ring) owner(key string, candidates []string) string {
+	var best string
+	var bestScore uint64
+	for _, c := range candidates {
+		score := rendezvousScore(key, c)
+		if best == "" || score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	return best
+}
+
+// rendezvousScore combines key and candidate into a single hash so owner
+// can rank candidates without maintaining a stable hash ring structure.
+func rendezvousScore(key, candidate string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(candidate))
+	return h.Sum64()
+}
@@ -0,0 +1,54 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package cluster
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRingOwnerStable(t *testing.T) {
+	candidates := []string{"a:1", "b:1", "c:1"}
+	want := ring{}.owner("metric.name", candidates)
+	for i := 0; i < 100; i++ {
+		if got := (ring{}).owner("metric.name", candidates); got != want {
+			t.Fatalf("owner is non-deterministic: got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestRingOwnerWithinCandidates(t *testing.T) {
+	candidates := []string{"a:1", "b:1", "c:1"}
+	owner := ring{}.owner("metric.name", candidates)
+	found := false
+	for _, c := range candidates {
+		if c == owner {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("owner %q is not among candidates %v", owner, candidates)
+	}
+}
+
+func TestRingOwnerLosingPeerOnlyReshufflesSomeKeys(t *testing.T) {
+	full := []string{"a:1", "b:1", "c:1", "d:1"}
+	reduced := []string{"a:1", "b:1", "c:1"}
+	changed, unchanged := 0, 0
+	for i := 0; i < 1000; i++ {
+		key := "metric." + strconv.Itoa(i)
+		before := ring{}.owner(key, full)
+		after := ring{}.owner(key, reduced)
+		if before == "d:1" {
+			continue // d:1's keys must move, that's expected.
+		}
+		if before == after {
+			unchanged++
+		} else {
+			changed++
+		}
+	}
+	if changed != 0 {
+		t.Errorf("%d keys not owned by the removed peer still moved after it left", changed)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package cluster
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// clusterStatus is the JSON shape served by StatusHandler.
+type clusterStatus struct {
+	Self  string            `json:"self"`
+	Peers map[string]string `json:"peers"` // addr -> "healthy" | "quarantined"
+	// Ring is every address ownerOf ranks candidates from, ie. self plus
+	// every peer currently considered healthy. It's what actually decides
+	// owner assignments; Peers alone can't tell an operator that, since a
+	// quarantined peer is excluded from ownership without being removed
+	// from Peers.
+	Ring []string `json:"ring"`
+	// Owner is the current owner of the metric name given in the "name"
+	// query parameter, omitted when that parameter isn't set.
+	Owner string `json:"owner,omitempty"`
+}
+
+// StatusHandler returns an http.Handler exposing this node's view of the
+// cluster for debugging owner assignments: itself, every peer and whether
+// it's healthy or quarantined, the ring of addresses ownership is actually
+// computed over, and, given a "name" query parameter, which address
+// currently owns that metric name. Callers mount it at "/cluster" alongside
+// the rest of the webapp's routes.
+func (c *Cluster) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.RLock()
+		s := clusterStatus{Self: c.self, Peers: make(map[string]string, len(c.peers))}
+		s.Ring = append(s.Ring, c.self)
+		for addr, p := range c.peers {
+			if p.isQuarantined() {
+				s.Peers[addr] = "quarantined"
+			} else {
+				s.Peers[addr] = "healthy"
+				s.Ring = append(s.Ring, addr)
+			}
+		}
+		c.mu.RUnlock()
+		if name := r.URL.Query().Get("name"); name != "" {
+			s.Owner = c.ownerOf(name)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s)
+	})
+}
@@ -0,0 +1,39 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package detector
+
+import (
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/detector/cluster"
+	"github.com/eleme/banshee/detector/input"
+)
+
+// inputConfigsFrom adapts the plain config.InputConfig list operators
+// write in config.Config into the input.Config shape New expects. config
+// is kept a leaf package that imports nothing from the detector tree, so
+// the detector package does the translation at its own boundary instead.
+func inputConfigsFrom(cfgs []config.InputConfig) []input.Config {
+	out := make([]input.Config, len(cfgs))
+	for i, c := range cfgs {
+		out[i] = input.Config{
+			Protocol:  c.Protocol,
+			Port:      c.Port,
+			Transport: c.Transport,
+			Alias:     c.Alias,
+		}
+	}
+	return out
+}
+
+// clusterConfigFrom adapts a config.ClusterConfig into the cluster.Config
+// shape cluster.New expects, for the same layering reason as
+// inputConfigsFrom.
+func clusterConfigFrom(c *config.ClusterConfig) cluster.Config {
+	return cluster.Config{
+		Self:              c.Self,
+		Peers:             c.Peers,
+		Etcd:              c.Etcd,
+		EtcdPrefix:        c.EtcdPrefix,
+		HeartbeatInterval: c.HeartbeatInterval,
+	}
+}
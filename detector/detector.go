@@ -5,16 +5,19 @@
 package detector
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"net"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/detector/aggregator"
+	"github.com/eleme/banshee/detector/cluster"
 	"github.com/eleme/banshee/detector/cursor"
+	"github.com/eleme/banshee/detector/input"
 	"github.com/eleme/banshee/models"
 	"github.com/eleme/banshee/storage"
-	"github.com/eleme/banshee/storage/statedb"
 	"github.com/eleme/banshee/util"
 	"github.com/eleme/banshee/util/log"
 )
@@ -35,76 +38,238 @@ type Detector struct {
 	hitCache *cache
 	// Cursor
 	cursor *cursor.Cursor
+	// State batcher
+	batcher *stateBatcher
+	// Window aggregation
+	aggregator *aggregator.RunningAggregator
+	// Federation: nil unless cfg.Detector.Cluster is configured.
+	cluster  *cluster.Cluster
+	originOf sync.Map // in-flight *models.Metric -> origin node address
+	// Lifecycle
+	ctx    context.Context
+	cancel context.CancelFunc
+	// wg tracks every local producer that can call batcher.submit: input
+	// Listen goroutines and the aggregator flusher. batchWg tracks only the
+	// state batcher's own shard workers. They're kept separate, and
+	// batcherStop is only closed once wg.Wait() has returned and, for
+	// clustered nodes, cluster.Stop() has drained handleConn too, so a
+	// shard never stops consuming while a producer might still be
+	// mid-submit. See Stop.
+	wg          sync.WaitGroup
+	batchWg     sync.WaitGroup
+	batcherStop chan struct{}
+	done        chan struct{}
 }
 
 // New creates a detector.
-func New(cfg *config.Config, db *storage.DB) *Detector {
+func New(ctx context.Context, cfg *config.Config, db *storage.DB) *Detector {
 	d := new(Detector)
+	d.ctx, d.cancel = context.WithCancel(ctx)
 	d.cfg = cfg
 	d.db = db
 	d.rc = make(chan *models.Metric, bufferedMetricResultsLimit)
 	d.hitCache = newCache()
 	d.cursor = cursor.New(cfg.Detector.Factor, cfg.LeastC())
+	d.done = make(chan struct{})
+	d.batcherStop = make(chan struct{})
+	d.batcher = newStateBatcher(db, d.cursor, cfg, &d.batchWg, d.batcherStop, d.onDetected, d.onSkipped)
+	d.aggregator = aggregator.NewRunningAggregator()
+	d.wg.Add(1)
+	go d.runAggregatorFlusher()
+	if cfg.Detector.Cluster != nil {
+		cl, err := cluster.New(clusterConfigFrom(cfg.Detector.Cluster), d.onOwnedMetric, d.onClusterResult)
+		if err != nil {
+			log.Fatal("cluster: %v", err)
+		}
+		d.cluster = cl
+	}
 	return d
 }
 
-// Start detector.
-func (d *Detector) Start() {
-	addr := fmt.Sprintf("0.0.0.0:%d", d.cfg.Detector.Port)
-	ln, err := net.Listen("tcp", addr)
-	if err != nil {
-		log.Fatal("failed to bind tcp://%s: %v", addr, err)
+// Start every configured input and funnel the metrics they parse through
+// match/detect, until Stop is called or an input fails to start. Callers
+// should run it in its own goroutine.
+func (d *Detector) Start() error {
+	if d.cluster != nil {
+		if err := d.cluster.Start(d.ctx); err != nil {
+			return fmt.Errorf("failed to start cluster: %v", err)
+		}
+	}
+	cfgs := inputConfigsFrom(d.cfg.Detector.Inputs)
+	if len(cfgs) == 0 {
+		// No inputs configured, fall back to the historical single
+		// banshee-format tcp listener on Detector.Port.
+		cfgs = []input.Config{{Protocol: "banshee", Port: d.cfg.Detector.Port}}
 	}
-	log.Info("listening on tcp://%s..", addr)
-	for {
-		conn, err := ln.Accept()
+	ins := make([]input.Input, 0, len(cfgs))
+	for _, c := range cfgs {
+		in, err := input.New(c)
 		if err != nil {
-			log.Fatal("failed to accept new conn: %v", err)
+			return fmt.Errorf("failed to create input: %v", err)
 		}
-		go d.handle(conn)
+		ins = append(ins, in)
+	}
+	errc := make(chan error, len(ins))
+	for _, in := range ins {
+		d.wg.Add(1)
+		go func(in input.Input) {
+			defer d.wg.Done()
+			log.Info("input %s listening..", in.Name())
+			if err := in.Listen(d.ctx, &d.wg, d.onMetric); err != nil {
+				select {
+				case <-d.done:
+					// Stop was called, this shutdown error is expected.
+				default:
+					errc <- fmt.Errorf("input %s: %v", in.Name(), err)
+				}
+			}
+		}(in)
+	}
+	select {
+	case err := <-errc:
+		return err
+	case <-d.done:
+		return nil
 	}
 }
 
-// Handle a connection, it will filter the mertics by rules and detect whether
-// the metrics are anomalies.
-func (d *Detector) handle(conn net.Conn) {
-	addr := conn.RemoteAddr()
-	defer func() {
-		conn.Close()
-		log.Info("conn %s disconnected", addr)
-	}()
-	log.Info("conn %s established", addr)
-	// Scan line by line.
-	scanner := bufio.NewScanner(conn)
-	for scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			log.Info("read conn: %v, closing it..", err)
-			break
+// Stop the detector gracefully: it cancels every input's context, signals
+// the Start supervisor, waits for every local producer that could still
+// call batcher.submit to finish before letting the state batcher's shards
+// stop consuming, and only then drains the results channel. It gives up
+// and returns ctx.Err() if ctx is done before all of that has drained.
+//
+// The producer/consumer ordering matters: input Listen goroutines and
+// runAggregatorFlusher (tracked on d.wg) both call batcher.submit, and so
+// does a clustered node's own cluster.handleConn, forwarding peers' owned
+// metrics in via onOwnedMetric. If a shard stopped consuming on the same
+// cancellation signal those producers react to, a producer still
+// mid-submit after its shard already did its final drain would block on
+// that send forever, wedging the wait below and leaking the goroutine. So
+// batcherStop is only closed once every producer is confirmed done: first
+// d.wg.Wait() for the local ones, then cluster.Stop() — which itself waits
+// out every cluster goroutine including handleConn — for the clustered
+// one. batchWg.Wait() then confirms every shard has drained and flushed
+// whatever was left queued.
+func (d *Detector) Stop(ctx context.Context) error {
+	close(d.done)
+	d.cancel()
+	waitDone := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		if d.cluster != nil {
+			d.cluster.Stop()
 		}
-		startAt := time.Now()
-		line := scanner.Text()
-		m, err := parseMetric(line)
-		if err != nil {
-			if len(line) > 10 {
-				line = line[:10]
-			}
-			log.Error("parse '%s': %v, skipping..", line, err)
-			continue
+		close(d.batcherStop)
+		d.batchWg.Wait()
+		close(waitDone)
+	}()
+	select {
+	case <-waitDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	// Drain buffered results so alerters fed by d.rc can finish consuming
+	// before the channel goes away.
+	close(d.rc)
+	for range d.rc {
+	}
+	return nil
+}
+
+// Done returns a channel that's closed once Stop has been called, so main
+// can coordinate shutdown of the detector alongside the webapp and alerter.
+func (d *Detector) Done() <-chan struct{} {
+	return d.done
+}
+
+// ClusterStatusHandler returns an http.Handler exposing this node's cluster
+// membership and ownership for debugging, or nil if clustering isn't
+// configured. The webapp mounts it at "/cluster".
+func (d *Detector) ClusterStatusHandler() http.Handler {
+	if d.cluster == nil {
+		return nil
+	}
+	return d.cluster.StatusHandler()
+}
+
+// onMetric filters a metric parsed by any input against the rules and, if
+// it matches, either forwards it to its owning peer (when clustering is
+// configured and this node isn't the owner) or runs it through this node's
+// own aggregate/detect pipeline.
+func (d *Detector) onMetric(m *models.Metric) {
+	if !d.match(m) {
+		return
+	}
+	if d.cluster != nil && !d.cluster.Owns(m.Name) {
+		d.cluster.Forward(m)
+		return
+	}
+	d.process(d.self(), m)
+}
+
+// onOwnedMetric is the cluster's callback for metrics forwarded here
+// because this node owns them; origin is the node that originally received
+// m before forwarding it on.
+func (d *Detector) onOwnedMetric(origin string, m *models.Metric) {
+	d.process(origin, m)
+}
+
+// process runs the aggregate/detect pipeline for a metric this node owns,
+// threading origin through so the eventual result is published back to
+// whichever node should see it in its own results channel.
+func (d *Detector) process(origin string, m *models.Metric) {
+	if d.aggregate(d.ruleFor(m), m) {
+		if d.cluster != nil {
+			d.originOf.Store(m, origin)
 		}
-		if d.match(m) {
-			err = d.detect(m)
-			if err != nil {
-				log.Error("detect: %v, skipping..", err)
-				continue
-			}
-			elapsed := time.Since(startAt)
-			log.Debug("name=%s average=%.3f score=%.3f cost=%dμs", m.Name, m.Average, m.Score, elapsed.Nanoseconds()/1000)
-			select {
-			case d.rc <- m:
-			default:
-				log.Warn("buffered metric results channel is full, drop current metric..")
-			}
+		d.batcher.submit(m)
+	}
+}
+
+// self returns this node's cluster address, or "" when clustering isn't
+// configured.
+func (d *Detector) self() string {
+	if d.cluster == nil {
+		return ""
+	}
+	return d.cluster.Self()
+}
+
+// onDetected is called by the state batcher once m has been detected and
+// its next state staged for the batched write. elapsed covers loading m's
+// prior state on a shard cache miss plus the cursor.Next call, not the time
+// m spent queued waiting for its shard to flush.
+func (d *Detector) onDetected(m *models.Metric, elapsed time.Duration) {
+	log.Debug("name=%s average=%.3f score=%.3f cost=%dμs", m.Name, m.Average, m.Score, elapsed.Nanoseconds()/1000)
+	if d.cluster != nil {
+		origin := d.self()
+		if o, ok := d.originOf.Load(m); ok {
+			origin = o.(string)
+			d.originOf.Delete(m)
 		}
+		d.cluster.PublishResult(origin, m)
+		return
+	}
+	d.onClusterResult(m)
+}
+
+// onSkipped is called by the state batcher for a metric it staged in
+// process but then dropped before detecting, eg. because loading its prior
+// state failed. It only needs to undo what process did: forget the
+// pending origin entry so a load failure doesn't leak it forever.
+func (d *Detector) onSkipped(m *models.Metric) {
+	d.originOf.Delete(m)
+}
+
+// onClusterResult publishes a detected metric to this node's own results
+// channel, whether it was detected locally or is a result routed back here
+// by a peer that owns the metric.
+func (d *Detector) onClusterResult(m *models.Metric) {
+	select {
+	case d.rc <- m:
+	default:
+		log.Warn("buffered metric results channel is full, drop current metric..")
 	}
 }
 
@@ -137,21 +302,3 @@ func (d *Detector) match(m *models.Metric) bool {
 	log.Debug("%s hit no rules", m.Name)
 	return false
 }
-
-// Detect incoming metric with 3-sigma rule and fill the metric.Score.
-func (d *Detector) detect(m *models.Metric) error {
-	// Get pervious state.
-	s, err := d.db.State.Get(m)
-	if err != nil && err != statedb.ErrNotFound {
-		return err
-	}
-	// Move state next.
-	var n *models.State
-	if err == statedb.ErrNotFound {
-		n = d.cursor.Next(nil, m)
-	} else {
-		n = d.cursor.Next(s, m)
-	}
-	// Put the next state to db.
-	return d.db.State.Put(m, n)
-}
\ No newline at end of file
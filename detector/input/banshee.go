@@ -0,0 +1,76 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package input
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util/log"
+)
+
+// bansheeInput parses banshee's own tcp line format: "name stamp value",
+// space separated, one metric per line.
+type bansheeInput struct {
+	cfg Config
+}
+
+func newBansheeInput(cfg Config) *bansheeInput {
+	return &bansheeInput{cfg: cfg}
+}
+
+// Name implements Input.
+func (in *bansheeInput) Name() string {
+	return in.cfg.name("banshee")
+}
+
+// Parse implements Input.
+func (in *bansheeInput) Parse(line []byte) ([]*models.Metric, error) {
+	fields := strings.Fields(string(line))
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("expect 3 fields, got %d", len(fields))
+	}
+	stamp, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse stamp: %v", err)
+	}
+	value, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse value: %v", err)
+	}
+	m := &models.Metric{Name: fields[0], Stamp: stamp, Value: value}
+	if in.cfg.Alias != "" {
+		m.Name = in.cfg.Alias + "." + m.Name
+	}
+	return []*models.Metric{m}, nil
+}
+
+// Listen implements Input.
+func (in *bansheeInput) Listen(ctx context.Context, wg *sync.WaitGroup, onMetric func(*models.Metric)) error {
+	parseLine := func(line []byte) {
+		ms, err := in.Parse(line)
+		if err != nil {
+			s := string(line)
+			if len(s) > 10 {
+				s = s[:10]
+			}
+			log.Error("%s: parse '%s': %v, skipping..", in.Name(), s, err)
+			return
+		}
+		for _, m := range ms {
+			onMetric(m)
+		}
+	}
+	switch in.cfg.transport("tcp") {
+	case "tcp":
+		return listenTCP(ctx, in.cfg.Port, wg, parseLine)
+	case "udp":
+		return listenUDP(ctx, in.cfg.Port, parseLine)
+	default:
+		return fmt.Errorf("banshee input: unsupported transport %q", in.cfg.Transport)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package input
+
+import "fmt"
+
+// Config describes how to configure and run a single Input. Operators list
+// one of these per listener in config.Config, so several inputs (even of
+// the same protocol on different ports) can run side by side.
+type Config struct {
+	// Protocol selects the wire format: "banshee" (the default), "graphite",
+	// "statsd" or "influx".
+	Protocol string `json:"protocol"`
+	// Port is the tcp/udp port this input listens on.
+	Port int `json:"port"`
+	// Transport is "tcp" or "udp". Defaults to the protocol's usual
+	// transport when empty: tcp for banshee/graphite, udp for statsd/influx.
+	Transport string `json:"transport"`
+	// Alias prefixes parsed metric names and identifies the input in log
+	// lines, mirroring Telegraf's input alias, so operators can run several
+	// inputs of the same protocol side by side.
+	Alias string `json:"alias"`
+}
+
+// transport returns cfg.Transport, falling back to def when unset.
+func (cfg Config) transport(def string) string {
+	if cfg.Transport == "" {
+		return def
+	}
+	return cfg.Transport
+}
+
+// name returns cfg.Alias if set, otherwise def.
+func (cfg Config) name(def string) string {
+	if cfg.Alias != "" {
+		return cfg.Alias
+	}
+	return def
+}
+
+// New creates the Input named by cfg.Protocol.
+func New(cfg Config) (Input, error) {
+	switch cfg.Protocol {
+	case "", "banshee":
+		return newBansheeInput(cfg), nil
+	case "graphite":
+		return newGraphiteInput(cfg), nil
+	case "statsd":
+		return newStatsdInput(cfg), nil
+	case "influx":
+		return newInfluxInput(cfg), nil
+	default:
+		return nil, fmt.Errorf("input: unknown protocol %q", cfg.Protocol)
+	}
+}
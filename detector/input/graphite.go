@@ -0,0 +1,76 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package input
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util/log"
+)
+
+// graphiteInput parses Graphite's plaintext protocol: "name value
+// timestamp", space separated, one metric per line.
+type graphiteInput struct {
+	cfg Config
+}
+
+func newGraphiteInput(cfg Config) *graphiteInput {
+	return &graphiteInput{cfg: cfg}
+}
+
+// Name implements Input.
+func (in *graphiteInput) Name() string {
+	return in.cfg.name("graphite")
+}
+
+// Parse implements Input.
+func (in *graphiteInput) Parse(line []byte) ([]*models.Metric, error) {
+	fields := strings.Fields(string(line))
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("expect 3 fields, got %d", len(fields))
+	}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse value: %v", err)
+	}
+	stamp, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse timestamp: %v", err)
+	}
+	name := fields[0]
+	if in.cfg.Alias != "" {
+		name = in.cfg.Alias + "." + name
+	}
+	return []*models.Metric{{Name: name, Stamp: stamp, Value: value}}, nil
+}
+
+// Listen implements Input.
+func (in *graphiteInput) Listen(ctx context.Context, wg *sync.WaitGroup, onMetric func(*models.Metric)) error {
+	parseLine := func(line []byte) {
+		ms, err := in.Parse(line)
+		if err != nil {
+			s := string(line)
+			if len(s) > 10 {
+				s = s[:10]
+			}
+			log.Error("%s: parse '%s': %v, skipping..", in.Name(), s, err)
+			return
+		}
+		for _, m := range ms {
+			onMetric(m)
+		}
+	}
+	switch in.cfg.transport("tcp") {
+	case "tcp":
+		return listenTCP(ctx, in.cfg.Port, wg, parseLine)
+	case "udp":
+		return listenUDP(ctx, in.cfg.Port, parseLine)
+	default:
+		return fmt.Errorf("graphite input: unsupported transport %q", in.cfg.Transport)
+	}
+}
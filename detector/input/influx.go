@@ -0,0 +1,127 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package input
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util/log"
+)
+
+// influxInput parses InfluxDB's line protocol:
+// "measurement,tag1=v1,tag2=v2 field1=1,field2=2 timestamp". Tags are
+// appended as dotted suffixes on the measurement name, and each field
+// becomes its own banshee metric named "measurement.tagv1.tagv2.field".
+type influxInput struct {
+	cfg Config
+}
+
+func newInfluxInput(cfg Config) *influxInput {
+	return &influxInput{cfg: cfg}
+}
+
+// Name implements Input.
+func (in *influxInput) Name() string {
+	return in.cfg.name("influx")
+}
+
+// Parse implements Input.
+func (in *influxInput) Parse(line []byte) ([]*models.Metric, error) {
+	fields := strings.Fields(string(line))
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("expect 2 or 3 fields, got %d", len(fields))
+	}
+	measurement, tagValues, err := parseInfluxKey(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	stamp := int64(0)
+	if len(fields) == 3 {
+		stamp, err = strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse timestamp: %v", err)
+		}
+	}
+	fieldSet, err := parseInfluxFields(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	name := strings.Join(append([]string{measurement}, tagValues...), ".")
+	if in.cfg.Alias != "" {
+		name = in.cfg.Alias + "." + name
+	}
+	ms := make([]*models.Metric, 0, len(fieldSet))
+	for field, value := range fieldSet {
+		ms = append(ms, &models.Metric{Name: name + "." + field, Stamp: stamp, Value: value})
+	}
+	return ms, nil
+}
+
+// parseInfluxKey splits "measurement,tag1=v1,tag2=v2" into the measurement
+// name and the ordered list of tag values.
+func parseInfluxKey(key string) (measurement string, tagValues []string, err error) {
+	parts := strings.Split(key, ",")
+	measurement = parts[0]
+	if measurement == "" {
+		return "", nil, fmt.Errorf("missing measurement")
+	}
+	for _, tag := range parts[1:] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			return "", nil, fmt.Errorf("malformed tag %q", tag)
+		}
+		tagValues = append(tagValues, kv[1])
+	}
+	return measurement, tagValues, nil
+}
+
+// parseInfluxFields parses "field1=1,field2=2" into a field name to value
+// map, dropping the trailing "i" integer suffix InfluxDB allows. Quoted
+// string field values don't parse as numbers and make the line an error.
+func parseInfluxFields(fieldset string) (map[string]float64, error) {
+	result := make(map[string]float64)
+	for _, kv := range strings.Split(fieldset, ",") {
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("malformed field %q", kv)
+		}
+		raw := strings.TrimSuffix(pair[1], "i")
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse field %q: %v", pair[0], err)
+		}
+		result[pair[0]] = value
+	}
+	return result, nil
+}
+
+// Listen implements Input.
+func (in *influxInput) Listen(ctx context.Context, wg *sync.WaitGroup, onMetric func(*models.Metric)) error {
+	parseLine := func(line []byte) {
+		ms, err := in.Parse(line)
+		if err != nil {
+			s := string(line)
+			if len(s) > 10 {
+				s = s[:10]
+			}
+			log.Error("%s: parse '%s': %v, skipping..", in.Name(), s, err)
+			return
+		}
+		for _, m := range ms {
+			onMetric(m)
+		}
+	}
+	switch in.cfg.transport("udp") {
+	case "udp":
+		return listenUDP(ctx, in.cfg.Port, parseLine)
+	case "tcp":
+		return listenTCP(ctx, in.cfg.Port, wg, parseLine)
+	default:
+		return fmt.Errorf("influx input: unsupported transport %q", in.cfg.Transport)
+	}
+}
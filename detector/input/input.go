@@ -0,0 +1,30 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+// Package input provides pluggable listeners that accept metrics over
+// different wire protocols and decode them into models.Metric values,
+// modeled after Telegraf's input plugins: each protocol is a small,
+// self-contained Input that knows how to listen and how to parse its own
+// line format, so the detector can run several of them side by side.
+package input
+
+import (
+	"context"
+	"sync"
+
+	"github.com/eleme/banshee/models"
+)
+
+// Input is a pluggable protocol listener.
+type Input interface {
+	// Name returns the input's identity for logging, either its protocol
+	// name or its configured alias.
+	Name() string
+	// Parse decodes a single line into zero or more metrics, a single
+	// InfluxDB line can expand into several banshee metrics, one per field.
+	Parse(line []byte) ([]*models.Metric, error)
+	// Listen accepts connections or packets and calls onMetric for every
+	// successfully parsed metric, until ctx is done. Every goroutine Listen
+	// spawns to service a connection is tracked on wg before it starts, so
+	// callers can wg.Wait() to drain them alongside Listen's own return.
+	Listen(ctx context.Context, wg *sync.WaitGroup, onMetric func(*models.Metric)) error
+}
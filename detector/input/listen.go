@@ -0,0 +1,86 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// listenTCP accepts connections on port and calls parseLine with every
+// scanned line from every connection, until ctx is done. wg tracks every
+// per-connection goroutine it spawns, so callers can wg.Wait() to drain
+// them instead of leaking handlers past shutdown.
+func listenTCP(ctx context.Context, port int, wg *sync.WaitGroup, parseLine func(line []byte)) error {
+	addr := fmt.Sprintf("0.0.0.0:%d", port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to bind tcp://%s: %v", addr, err)
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+				return fmt.Errorf("failed to accept new conn: %v", err)
+			}
+		}
+		wg.Add(1)
+		go func(conn net.Conn) {
+			defer wg.Done()
+			defer conn.Close()
+			go func() {
+				<-ctx.Done()
+				conn.Close()
+			}()
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				parseLine(scanner.Bytes())
+			}
+		}(conn)
+	}
+}
+
+// listenUDP reads datagrams on port and calls parseLine with every
+// newline-delimited line in every packet, until ctx is done. Packets are
+// parsed inline on the read loop rather than in their own goroutines, so
+// no WaitGroup tracking is needed here.
+func listenUDP(ctx context.Context, port int, parseLine func(line []byte)) error {
+	addr := fmt.Sprintf("0.0.0.0:%d", port)
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to bind udp://%s: %v", addr, err)
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+				return fmt.Errorf("failed to read udp packet: %v", err)
+			}
+		}
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			if len(line) > 0 {
+				parseLine(line)
+			}
+		}
+	}
+}
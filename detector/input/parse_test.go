@@ -0,0 +1,80 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package input
+
+import "testing"
+
+func TestBansheeParse(t *testing.T) {
+	in := newBansheeInput(Config{})
+	ms, err := in.Parse([]byte("api.latency 1000 1.5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 || ms[0].Name != "api.latency" || ms[0].Stamp != 1000 || ms[0].Value != 1.5 {
+		t.Fatalf("got %+v", ms)
+	}
+	if _, err := in.Parse([]byte("too few fields")); err == nil {
+		t.Fatal("expected an error for a malformed line")
+	}
+}
+
+func TestBansheeParseAlias(t *testing.T) {
+	in := newBansheeInput(Config{Alias: "prod"})
+	ms, err := in.Parse([]byte("api.latency 1000 1.5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms[0].Name != "prod.api.latency" {
+		t.Errorf("name = %q, want %q", ms[0].Name, "prod.api.latency")
+	}
+}
+
+func TestGraphiteParse(t *testing.T) {
+	in := newGraphiteInput(Config{})
+	ms, err := in.Parse([]byte("api.latency 1.5 1000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 || ms[0].Name != "api.latency" || ms[0].Stamp != 1000 || ms[0].Value != 1.5 {
+		t.Fatalf("got %+v", ms)
+	}
+}
+
+func TestStatsdParse(t *testing.T) {
+	in := newStatsdInput(Config{})
+	ms, err := in.Parse([]byte("api.latency:120|ms"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 || ms[0].Name != "api.latency" || ms[0].Value != 120 {
+		t.Fatalf("got %+v", ms)
+	}
+	if _, err := in.Parse([]byte("missing-separator")); err == nil {
+		t.Fatal("expected an error for a missing ':' separator")
+	}
+}
+
+func TestInfluxParse(t *testing.T) {
+	in := newInfluxInput(Config{})
+	ms, err := in.Parse([]byte("cpu,host=a usage=1.5,idle=98 1000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64, len(ms))
+	for _, m := range ms {
+		got[m.Name] = m.Value
+		if m.Stamp != 1000 {
+			t.Errorf("%s stamp = %d, want 1000", m.Name, m.Stamp)
+		}
+	}
+	if got["cpu.a.usage"] != 1.5 || got["cpu.a.idle"] != 98 {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestInfluxParseMissingMeasurement(t *testing.T) {
+	in := newInfluxInput(Config{})
+	if _, err := in.Parse([]byte(",host=a usage=1.5")); err == nil {
+		t.Fatal("expected an error for a missing measurement")
+	}
+}
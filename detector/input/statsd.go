@@ -0,0 +1,79 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package input
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/util/log"
+)
+
+// statsdInput parses StatsD's wire format: "name:value|type", eg.
+// "api.latency:120|ms", optionally followed by a "|@rate" sample rate which
+// is ignored. Timestamps aren't carried on the wire, so each metric is
+// stamped with its arrival time.
+type statsdInput struct {
+	cfg Config
+}
+
+func newStatsdInput(cfg Config) *statsdInput {
+	return &statsdInput{cfg: cfg}
+}
+
+// Name implements Input.
+func (in *statsdInput) Name() string {
+	return in.cfg.name("statsd")
+}
+
+// Parse implements Input.
+func (in *statsdInput) Parse(line []byte) ([]*models.Metric, error) {
+	nameValue := strings.SplitN(string(line), ":", 2)
+	if len(nameValue) != 2 {
+		return nil, fmt.Errorf("missing ':' separator")
+	}
+	rest := strings.Split(nameValue[1], "|")
+	if len(rest) < 2 {
+		return nil, fmt.Errorf("missing '|type' suffix")
+	}
+	value, err := strconv.ParseFloat(rest[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse value: %v", err)
+	}
+	name := nameValue[0]
+	if in.cfg.Alias != "" {
+		name = in.cfg.Alias + "." + name
+	}
+	return []*models.Metric{{Name: name, Stamp: time.Now().Unix(), Value: value}}, nil
+}
+
+// Listen implements Input.
+func (in *statsdInput) Listen(ctx context.Context, wg *sync.WaitGroup, onMetric func(*models.Metric)) error {
+	parseLine := func(line []byte) {
+		ms, err := in.Parse(line)
+		if err != nil {
+			s := string(line)
+			if len(s) > 10 {
+				s = s[:10]
+			}
+			log.Error("%s: parse '%s': %v, skipping..", in.Name(), s, err)
+			return
+		}
+		for _, m := range ms {
+			onMetric(m)
+		}
+	}
+	switch in.cfg.transport("udp") {
+	case "udp":
+		return listenUDP(ctx, in.cfg.Port, parseLine)
+	case "tcp":
+		return listenTCP(ctx, in.cfg.Port, wg, parseLine)
+	default:
+		return fmt.Errorf("statsd input: unsupported transport %q", in.cfg.Transport)
+	}
+}
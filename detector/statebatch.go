@@ -0,0 +1,170 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package detector
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/eleme/banshee/config"
+	"github.com/eleme/banshee/detector/cursor"
+	"github.com/eleme/banshee/models"
+	"github.com/eleme/banshee/storage"
+	"github.com/eleme/banshee/storage/statedb"
+	"github.com/eleme/banshee/util/log"
+)
+
+// Defaults for state write-back batching, used whenever the config leaves
+// them unset.
+const (
+	defaultNumStateShards = 8
+	defaultFlushInterval  = 200 * time.Millisecond
+	defaultMaxBatchSize   = 1024
+)
+
+// stateBatcher shards the detect hot path's state reads/writes across N
+// worker goroutines, each owning a write-back cache keyed by metric name.
+// A metric's name always hashes to the same shard, so per-metric ordering
+// is preserved even though shards run concurrently. Each shard flushes its
+// pending states as a single storage/statedb.Batch whenever its queue hits
+// maxBatchSize or its flush ticker fires, whichever comes first, trading a
+// little latency for collapsing many Puts into one leveldb write.
+type stateBatcher struct {
+	db     *storage.DB
+	cursor *cursor.Cursor
+	wg     *sync.WaitGroup
+	shards []*stateShard
+}
+
+// stateShard is one worker's inbox and write-back cache.
+type stateShard struct {
+	in            chan *models.Metric
+	cache         map[string]*models.State
+	maxBatchSize  int
+	flushInterval time.Duration
+}
+
+// newStateBatcher creates a stateBatcher and starts its shard workers. wg
+// is dedicated to the shard workers alone (distinct from the detector's own
+// WaitGroup of producers that call submit), and closing must only be
+// closed once every such producer has permanently stopped calling submit:
+// closing it any earlier races a producer's in-flight submit against a
+// shard that has already drained s.in for the last time, blocking that
+// producer's send forever. See Detector.Stop for the ordering this relies
+// on.
+func newStateBatcher(db *storage.DB, cur *cursor.Cursor, cfg *config.Config, wg *sync.WaitGroup, closing <-chan struct{}, onDetected func(m *models.Metric, cost time.Duration), onSkipped func(m *models.Metric)) *stateBatcher {
+	n := cfg.Detector.StateShards
+	if n <= 0 {
+		n = defaultNumStateShards
+	}
+	flushInterval := cfg.Detector.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+	maxBatchSize := cfg.Detector.MaxBatchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = defaultMaxBatchSize
+	}
+	b := &stateBatcher{db: db, cursor: cur, wg: wg}
+	b.shards = make([]*stateShard, n)
+	for i := 0; i < n; i++ {
+		s := &stateShard{
+			in:            make(chan *models.Metric, maxBatchSize),
+			cache:         make(map[string]*models.State),
+			maxBatchSize:  maxBatchSize,
+			flushInterval: flushInterval,
+		}
+		b.shards[i] = s
+		wg.Add(1)
+		go func(s *stateShard) {
+			defer wg.Done()
+			b.runShard(closing, s, onDetected, onSkipped)
+		}(s)
+	}
+	return b
+}
+
+// shardFor returns the shard metric m is routed to.
+func (b *stateBatcher) shardFor(m *models.Metric) *stateShard {
+	h := fnv.New32a()
+	h.Write([]byte(m.Name))
+	return b.shards[h.Sum32()%uint32(len(b.shards))]
+}
+
+// submit routes m to its shard for batched detection.
+func (b *stateBatcher) submit(m *models.Metric) {
+	b.shardFor(m).in <- m
+}
+
+// runShard detects metrics from s.in until closing fires, flushing
+// whichever of maxBatchSize or flushInterval comes first, then drains and
+// flushes whatever's left queued before returning. closing must only fire
+// once every producer that could still call submit has stopped, or a
+// producer's send to a shard that already did its final drain blocks
+// forever.
+func (b *stateBatcher) runShard(closing <-chan struct{}, s *stateShard, onDetected func(m *models.Metric, cost time.Duration), onSkipped func(m *models.Metric)) {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	pending := make([]*models.Metric, 0, s.maxBatchSize)
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		batch := statedb.NewBatch()
+		for _, m := range pending {
+			startAt := time.Now()
+			state, ok := s.cache[m.Name]
+			if !ok {
+				var err error
+				state, err = b.loadState(m)
+				if err != nil {
+					log.Error("load state for %s: %v, skipping..", m.Name, err)
+					onSkipped(m)
+					continue
+				}
+			}
+			n := b.cursor.Next(state, m)
+			s.cache[m.Name] = n
+			batch.Put(m, n)
+			onDetected(m, time.Since(startAt))
+		}
+		if batch.Len() > 0 {
+			if err := b.db.State.WriteBatch(batch); err != nil {
+				log.Error("flush state batch: %v, dropping %d states..", err, batch.Len())
+			}
+		}
+		pending = pending[:0]
+	}
+	for {
+		select {
+		case m := <-s.in:
+			pending = append(pending, m)
+			if len(pending) >= s.maxBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-closing:
+			for {
+				select {
+				case m := <-s.in:
+					pending = append(pending, m)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// loadState fetches m's previous state, treating "not found" as a fresh
+// metric with no prior state.
+func (b *stateBatcher) loadState(m *models.Metric) (*models.State, error) {
+	s, err := b.db.State.Get(m)
+	if err == statedb.ErrNotFound {
+		return nil, nil
+	}
+	return s, err
+}
@@ -0,0 +1,41 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package detector
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/eleme/banshee/models"
+)
+
+func newTestBatcher(n int) *stateBatcher {
+	b := &stateBatcher{shards: make([]*stateShard, n)}
+	for i := range b.shards {
+		b.shards[i] = &stateShard{}
+	}
+	return b
+}
+
+func TestShardForStable(t *testing.T) {
+	b := newTestBatcher(8)
+	m := &models.Metric{Name: "api.latency"}
+	want := b.shardFor(m)
+	for i := 0; i < 10; i++ {
+		if got := b.shardFor(&models.Metric{Name: "api.latency"}); got != want {
+			t.Fatalf("shardFor(%q) is not stable across calls", m.Name)
+		}
+	}
+}
+
+func TestShardForDistributesNames(t *testing.T) {
+	b := newTestBatcher(8)
+	seen := make(map[*stateShard]bool)
+	for i := 0; i < 64; i++ {
+		name := "metric." + strconv.Itoa(i)
+		seen[b.shardFor(&models.Metric{Name: name})] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("64 distinct names landed on only %d of 8 shards", len(seen))
+	}
+}
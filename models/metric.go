@@ -0,0 +1,14 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+// Package models holds the data types shared across banshee's storage,
+// detector and webapp layers.
+package models
+
+// Metric is one named, timestamped sample flowing through the detector.
+type Metric struct {
+	Name    string  `json:"name"`
+	Stamp   int64   `json:"stamp"`
+	Value   float64 `json:"value"`
+	Average float64 `json:"average"`
+	Score   float64 `json:"score"`
+}
@@ -0,0 +1,19 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package models
+
+// Rule matches metric names against Pattern and configures how matched
+// metrics are aggregated before detection.
+type Rule struct {
+	Pattern string `json:"pattern"`
+	// Aggregator names the window aggregator ("basicstats", "histogram",
+	// "percentile") matched metrics are rolled up with, or "" to skip
+	// aggregation entirely.
+	Aggregator string `json:"aggregator"`
+	// Period is the aggregation window size, parsed with time.ParseDuration.
+	// Only meaningful when Aggregator is set.
+	Period string `json:"period"`
+	// DropOriginal suppresses the raw metric from the detect pipeline once
+	// Aggregator is set, so only the rolled-up series is detected.
+	DropOriginal bool `json:"drop_original"`
+}
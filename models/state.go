@@ -0,0 +1,22 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package models
+
+import "encoding/json"
+
+// State is a metric's 3-sigma cursor state, persisted between detections.
+type State struct {
+	Average float64 `json:"average"`
+	StdDev  float64 `json:"std_dev"`
+	Count   int     `json:"count"`
+}
+
+// Marshal serializes the state for storage.
+func (s *State) Marshal() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// Unmarshal deserializes a state previously written by Marshal.
+func (s *State) Unmarshal(data []byte) error {
+	return json.Unmarshal(data, s)
+}
@@ -0,0 +1,54 @@
+// Copyright 2015 Eleme Inc. All rights reserved.
+
+package statedb
+
+import (
+	"github.com/eleme/banshee/models"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Batch collects a set of per-metric state writes to commit to the store in
+// a single leveldb write batch, so callers that accumulate many states (eg.
+// the detector's per-shard write-back cache) can collapse N Puts into one
+// round trip.
+type Batch struct {
+	entries map[string]batchEntry
+}
+
+// batchEntry pairs a metric with the state staged for it, keeping the
+// metric around since it's needed to recompute the storage key.
+type batchEntry struct {
+	metric *models.Metric
+	state  *models.State
+}
+
+// NewBatch creates an empty Batch.
+func NewBatch() *Batch {
+	return &Batch{entries: make(map[string]batchEntry)}
+}
+
+// Put stages a state for metric m, overwriting any state already staged for
+// the same metric name.
+func (b *Batch) Put(m *models.Metric, s *models.State) {
+	b.entries[m.Name] = batchEntry{metric: m, state: s}
+}
+
+// Len returns the number of distinct metrics staged in b.
+func (b *Batch) Len() int {
+	return len(b.entries)
+}
+
+// WriteBatch commits every state staged in b to the store in a single
+// leveldb batch, collapsing what would otherwise be one Put per metric into
+// one write.
+func (db *DB) WriteBatch(b *Batch) error {
+	wb := new(leveldb.Batch)
+	for _, e := range b.entries {
+		value, err := e.state.Marshal()
+		if err != nil {
+			return err
+		}
+		wb.Put(stateKey(e.metric), value)
+	}
+	return db.ldb.Write(wb, nil)
+}